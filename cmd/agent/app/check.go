@@ -6,8 +6,10 @@
 package app
 
 import (
-	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -20,18 +22,29 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/collector/check"
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/serializer"
+	"github.com/DataDog/datadog-agent/pkg/serializer/textformat"
 	"github.com/DataDog/datadog-agent/pkg/status"
 	"github.com/DataDog/datadog-agent/pkg/util"
 )
 
 var (
-	checkRate  bool
-	checkTimes int
-	checkPause int
-	checkName  string
-	checkDelay int
-	logLevel   string
-	formatJSON bool
+	checkRate            bool
+	checkTimes           int
+	checkPause           int
+	checkName            string
+	checkDelay           int
+	logLevel             string
+	formatJSON           bool
+	checkSchedule        bool
+	checkInterval        string
+	checkIntervalURL     string
+	checkOutputFilter    string
+	checkFormat          string
+	checkAssertFile      string
+	checkRetry           int
+	checkRetryBackoff    string
+	checkRetryMaxElapsed string
+	checkBundle          string
 )
 
 // Make the check cmd aggregator never flush by setting a very high interval
@@ -46,6 +59,16 @@ func init() {
 	checkCmd.Flags().StringVarP(&logLevel, "log-level", "l", "", "set the log level (default 'off')")
 	checkCmd.Flags().IntVarP(&checkDelay, "delay", "d", 100, "delay between running the check and grabbing the metrics in miliseconds")
 	checkCmd.Flags().BoolVarP(&formatJSON, "json", "", false, "format aggregator output as json")
+	checkCmd.Flags().BoolVar(&checkSchedule, "schedule", false, "run the check on a loop instead of once, using --interval/--interval-url")
+	checkCmd.Flags().StringVar(&checkInterval, "interval", "60s", "interval between scheduled runs when --schedule is set, e.g. 30s, 1m")
+	checkCmd.Flags().StringVar(&checkIntervalURL, "interval-url", "", "URL polled between iterations to refresh the interval for each scheduled check")
+	checkCmd.Flags().StringVar(&checkOutputFilter, "output-filter", "", "comma-separated list of telemetry types to print: metrics,sketches,service_checks,events (default: all)")
+	checkCmd.Flags().StringVar(&checkFormat, "format", "pretty", "output format for check telemetry: json, pretty, influx, prometheus")
+	checkCmd.Flags().StringVar(&checkAssertFile, "assert-file", "", "path to a YAML/JSON file of expected metrics/service checks/events; exit non-zero if unmet")
+	checkCmd.Flags().IntVar(&checkRetry, "retry", 0, "number of times to retry a failed c.Run() with exponential backoff before recording the error")
+	checkCmd.Flags().StringVar(&checkRetryBackoff, "retry-backoff", "1s", "base delay between retries, doubled (plus jitter) after each attempt")
+	checkCmd.Flags().StringVar(&checkRetryMaxElapsed, "retry-max-elapsed", "0s", "cap total time spent retrying; 0 means unbounded")
+	checkCmd.Flags().StringVar(&checkBundle, "bundle", "", "write a support tarball (resolved config, aggregator payloads, status, warnings, logs) to this path")
 	checkCmd.SetArgs([]string{"checkName"})
 }
 
@@ -76,8 +99,21 @@ var checkCmd = &cobra.Command{
 			config.Datadog.Set("log_level", logLevel)
 		}
 
-		// Setup logger
-		err = config.SetupLogger(logLevel, "", "", false, true, false)
+		// Setup logger. When bundling, also mirror logs to a temp file so
+		// they can be packed into the support tarball.
+		bundleLogFile := ""
+		if checkBundle != "" {
+			f, err := ioutil.TempFile("", "agent-check-bundle-*.log")
+			if err != nil {
+				fmt.Printf("Cannot create temp log file for --bundle, exiting: %v\n", err)
+				return err
+			}
+			bundleLogFile = f.Name()
+			f.Close()
+			defer os.Remove(bundleLogFile)
+		}
+
+		err = config.SetupLogger(logLevel, bundleLogFile, "", false, true, false)
 		if err != nil {
 			fmt.Printf("Cannot setup logger, exiting: %v\n", err)
 			return err
@@ -129,22 +165,50 @@ var checkCmd = &cobra.Command{
 			fmt.Println("Multiple check instances found, running each of them")
 		}
 
+		if checkSchedule {
+			if checkBundle != "" || checkAssertFile != "" {
+				return fmt.Errorf("--schedule cannot be combined with --bundle or --assert-file, since a scheduled run never stops to report them")
+			}
+			return runScheduledChecks(cs, agg)
+		}
+
+		var checkStatuses [][]byte
+		var combined textformat.Snapshot
 		for _, c := range cs {
 			s := runCheck(c, agg)
 
 			// Sleep for a while to allow the aggregator to finish ingesting all the metrics/events/sc
 			time.Sleep(time.Duration(checkDelay) * time.Millisecond)
 
-			printMetrics(agg)
+			// buildSnapshot drains the aggregator's Get* accessors, so it must
+			// be read exactly once per run and reused by printMetrics and any
+			// --bundle/--assert-file handling below; calling it again later
+			// would just see an already-drained aggregator.
+			snapshot := buildSnapshot(agg)
+			printMetrics(snapshot)
+			combined = mergeSnapshots(combined, snapshot)
 
 			checkStatus, _ := status.GetCheckStatus(c, s)
+			checkStatuses = append(checkStatuses, checkStatus)
 			fmt.Println(string(checkStatus))
 		}
 
+		if checkBundle != "" {
+			if err := writeBundle(checkBundle, checkName, checkStatuses, combined, bundleLogFile); err != nil {
+				fmt.Printf("Cannot write --bundle %s: %v\n", checkBundle, err)
+				return err
+			}
+			fmt.Printf("Wrote support bundle to %s\n", checkBundle)
+		}
+
 		if checkRate == false && checkTimes < 2 && !formatJSON {
 			color.Yellow("Check has run only once, if some metrics are missing you can try again with --check-rate to see any other metric if available.")
 		}
 
+		if checkAssertFile != "" {
+			return runAssertions(checkAssertFile, combined)
+		}
+
 		return nil
 	},
 }
@@ -165,7 +229,7 @@ func runCheck(c check.Check, agg *aggregator.BufferedAggregator) *check.Stats {
 	}
 	for i := 0; i < times; i++ {
 		t0 := time.Now()
-		err := c.Run()
+		err := runOnce(c)
 		warnings := c.GetWarnings()
 		mStats, _ := c.GetMetricStats()
 		s.Add(time.Since(t0), err, warnings, mStats)
@@ -177,56 +241,96 @@ func runCheck(c check.Check, agg *aggregator.BufferedAggregator) *check.Stats {
 	return s
 }
 
-func printMetrics(agg *aggregator.BufferedAggregator) {
-	aggJSON := make(map[string]interface{})
-
-	series := agg.GetSeries()
-	if len(series) != 0 {
-		if formatJSON {
-			aggJSON["metrics"] = series
-		} else {
-			fmt.Fprintln(color.Output, fmt.Sprintf("=== %s ===", color.BlueString("Series")))
-			j, _ := json.MarshalIndent(series, "", "  ")
-			fmt.Println(string(j))
-		}
+// runOnce runs c once, transparently retrying with backoff when --retry is
+// set, and returns only the final error to record in check.Stats.
+func runOnce(c check.Check) error {
+	if checkRetry <= 0 {
+		return c.Run()
 	}
 
-	sketches := agg.GetSketches()
-	if len(sketches) != 0 {
-		if formatJSON {
-			aggJSON["sketches"] = sketches
-		} else {
-			fmt.Fprintln(color.Output, fmt.Sprintf("=== %s ===", color.BlueString("Sketches")))
-			j, _ := json.MarshalIndent(sketches, "", "  ")
-			fmt.Println(string(j))
-		}
+	backoff, err := time.ParseDuration(checkRetryBackoff)
+	if err != nil {
+		color.Yellow("invalid --retry-backoff %q, defaulting to 1s: %v", checkRetryBackoff, err)
+		backoff = time.Second
 	}
 
-	serviceChecks := agg.GetServiceChecks()
-	if len(serviceChecks) != 0 {
-		if formatJSON {
-			aggJSON["service_checks"] = serviceChecks
-		} else {
-			fmt.Fprintln(color.Output, fmt.Sprintf("=== %s ===", color.BlueString("Service Checks")))
-			j, _ := json.MarshalIndent(serviceChecks, "", "  ")
-			fmt.Println(string(j))
-		}
+	maxElapsed, err := time.ParseDuration(checkRetryMaxElapsed)
+	if err != nil {
+		color.Yellow("invalid --retry-max-elapsed %q, defaulting to 0s (unbounded): %v", checkRetryMaxElapsed, err)
+		maxElapsed = 0
 	}
 
-	events := agg.GetEvents()
-	if len(events) != 0 {
-		if formatJSON {
-			aggJSON["events"] = events
-		} else {
-			fmt.Fprintln(color.Output, fmt.Sprintf("=== %s ===", color.BlueString("Events")))
-			j, _ := json.MarshalIndent(events, "", "  ")
-			fmt.Println(string(j))
-		}
+	return check.RunWithBackoff(c, check.BackoffConfig{
+		MaxRetries: checkRetry,
+		Base:       backoff,
+		MaxElapsed: maxElapsed,
+	})
+}
+
+// buildSnapshot collects the current aggregator telemetry into a
+// textformat.Snapshot. The underlying Get* calls drain the aggregator, so
+// callers must read this exactly once per check run and reuse the result
+// (printMetrics, --bundle, --assert-file) rather than calling it again.
+func buildSnapshot(agg *aggregator.BufferedAggregator) textformat.Snapshot {
+	return textformat.Snapshot{
+		Series:        agg.GetSeries(),
+		Sketches:      agg.GetSketches(),
+		ServiceChecks: agg.GetServiceChecks(),
+		Events:        agg.GetEvents(),
 	}
+}
 
+// mergeSnapshots concatenates the telemetry of every check instance run
+// during this invocation, for --bundle/--assert-file to evaluate against.
+func mergeSnapshots(into, from textformat.Snapshot) textformat.Snapshot {
+	into.Series = append(into.Series, from.Series...)
+	into.Sketches = append(into.Sketches, from.Sketches...)
+	into.ServiceChecks = append(into.ServiceChecks, from.ServiceChecks...)
+	into.Events = append(into.Events, from.Events...)
+	return into
+}
+
+func printMetrics(snapshot textformat.Snapshot) {
+	formatterName := checkFormat
 	if formatJSON {
-		fmt.Fprintln(color.Output, fmt.Sprintf("=== %s ===", color.BlueString("JSON")))
-		j, _ := json.MarshalIndent(aggJSON, "", "  ")
-		fmt.Println(string(j))
+		// --json predates --format and is an alias for --format json.
+		formatterName = "json"
+	}
+
+	formatter, err := textformat.ForName(formatterName)
+	if err != nil {
+		fmt.Fprintln(color.Output, color.RedString("Error: %v", err))
+		return
+	}
+
+	out, err := formatter.Format(snapshot, parseOutputFilter(checkOutputFilter))
+	if err != nil {
+		fmt.Fprintln(color.Output, color.RedString("Error formatting output: %v", err))
+		return
+	}
+
+	fmt.Fprint(color.Output, out)
+}
+
+// parseOutputFilter turns the comma-separated --output-filter value into a
+// textformat.Filter, defaulting to rendering every telemetry type.
+func parseOutputFilter(raw string) textformat.Filter {
+	if raw == "" {
+		return textformat.DefaultFilter()
+	}
+
+	var f textformat.Filter
+	for _, part := range strings.Split(raw, ",") {
+		switch strings.TrimSpace(part) {
+		case "metrics":
+			f.Metrics = true
+		case "sketches":
+			f.Sketches = true
+		case "service_checks":
+			f.ServiceChecks = true
+		case "events":
+			f.Events = true
+		}
 	}
+	return f
 }
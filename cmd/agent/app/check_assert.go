@@ -0,0 +1,238 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+
+	"github.com/fatih/color"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/DataDog/datadog-agent/pkg/serializer/textformat"
+)
+
+// expectations is the schema of an `--assert-file`: a set of expected
+// metrics, service checks and events a check run must produce. The
+// comparators mirror the critical/warning/ok thresholds used by Datadog
+// monitors (gt, lt, eq, between over the latest reported value).
+type expectations struct {
+	Metrics       []metricExpectation      `yaml:"metrics"`
+	ServiceChecks []serviceCheckExpectation `yaml:"service_checks"`
+	Events        []eventExpectation       `yaml:"events"`
+}
+
+type metricExpectation struct {
+	Name       string            `yaml:"name"`
+	Tags       map[string]string `yaml:"tags"`
+	Comparator string            `yaml:"comparator"` // gt, lt, eq, between
+	Value      float64           `yaml:"value"`
+	High       float64           `yaml:"high"` // upper bound, only used by "between"
+}
+
+type serviceCheckExpectation struct {
+	Name string            `yaml:"name"`
+	Tags map[string]string `yaml:"tags"`
+	// Status is one of ok, warning, critical, unknown.
+	Status string `yaml:"status"`
+}
+
+type eventExpectation struct {
+	Title string `yaml:"title"`
+	Text  string `yaml:"text"`
+}
+
+// assertionFailure describes a single unmet expectation, for printing a
+// expected-vs-actual diff to the user.
+type assertionFailure struct {
+	Kind     string
+	Expected string
+	Actual   string
+}
+
+// runAssertions loads expectations from path, evaluates them against
+// snapshot and returns a non-nil error (after printing a diff) if any
+// expectation is unmet.
+func runAssertions(path string, snapshot textformat.Snapshot) error {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read assert-file %s: %v", path, err)
+	}
+
+	var exp expectations
+	if err := yaml.Unmarshal(raw, &exp); err != nil {
+		return fmt.Errorf("could not parse assert-file %s: %v", path, err)
+	}
+
+	failures := evaluateExpectations(exp, snapshot)
+	if len(failures) == 0 {
+		color.Green("All %d assertion(s) passed", len(exp.Metrics)+len(exp.ServiceChecks)+len(exp.Events))
+		return nil
+	}
+
+	fmt.Fprintln(color.Output, color.RedString("%d assertion(s) failed:", len(failures)))
+	for _, f := range failures {
+		fmt.Fprintf(color.Output, "* [%s] expected %s, got %s\n", f.Kind, f.Expected, f.Actual)
+	}
+
+	return fmt.Errorf("%d assertion(s) failed", len(failures))
+}
+
+func evaluateExpectations(exp expectations, snapshot textformat.Snapshot) []assertionFailure {
+	var failures []assertionFailure
+
+	for _, me := range exp.Metrics {
+		if f := evaluateMetric(me, snapshot); f != nil {
+			failures = append(failures, *f)
+		}
+	}
+	for _, sce := range exp.ServiceChecks {
+		if f := evaluateServiceCheck(sce, snapshot); f != nil {
+			failures = append(failures, *f)
+		}
+	}
+	for _, ee := range exp.Events {
+		if f := evaluateEvent(ee, snapshot); f != nil {
+			failures = append(failures, *f)
+		}
+	}
+
+	return failures
+}
+
+// validComparators are the metric comparators understood by compare. Any
+// other value is a malformed assert-file, not a failed assertion, so
+// evaluateMetric reports it distinctly rather than silently comparing false.
+var validComparators = map[string]bool{"gt": true, "lt": true, "eq": true, "between": true}
+
+func evaluateMetric(me metricExpectation, snapshot textformat.Snapshot) *assertionFailure {
+	if !validComparators[me.Comparator] {
+		return &assertionFailure{
+			Kind:     fmt.Sprintf("metric %s", me.Name),
+			Expected: expectedMetricString(me),
+			Actual:   fmt.Sprintf("unknown comparator %q (expected gt, lt, eq or between)", me.Comparator),
+		}
+	}
+
+	for _, serie := range snapshot.Series {
+		if serie.Name != me.Name || !tagsMatch(me.Tags, serie.Tags) {
+			continue
+		}
+		if len(serie.Points) == 0 {
+			continue
+		}
+		actual := serie.Points[len(serie.Points)-1].Value
+		if compare(me.Comparator, actual, me.Value, me.High) {
+			return nil
+		}
+		return &assertionFailure{
+			Kind:     fmt.Sprintf("metric %s", me.Name),
+			Expected: expectedMetricString(me),
+			Actual:   fmt.Sprintf("%v", actual),
+		}
+	}
+
+	return &assertionFailure{
+		Kind:     fmt.Sprintf("metric %s", me.Name),
+		Expected: expectedMetricString(me),
+		Actual:   "not reported",
+	}
+}
+
+func expectedMetricString(me metricExpectation) string {
+	if me.Comparator == "between" {
+		return fmt.Sprintf("%s between %v and %v", me.Name, me.Value, me.High)
+	}
+	return fmt.Sprintf("%s %s %v", me.Name, me.Comparator, me.Value)
+}
+
+// floatEqEpsilon bounds how close actual and value must be for the "eq"
+// comparator to match; metric values round-trip through aggregation and
+// serialization, so exact float equality would reject values that are
+// equal for any practical purpose.
+const floatEqEpsilon = 1e-9
+
+func compare(comparator string, actual, value, high float64) bool {
+	switch comparator {
+	case "gt":
+		return actual > value
+	case "lt":
+		return actual < value
+	case "eq":
+		return math.Abs(actual-value) <= floatEqEpsilon
+	case "between":
+		return actual >= value && actual <= high
+	default:
+		return false
+	}
+}
+
+func evaluateServiceCheck(sce serviceCheckExpectation, snapshot textformat.Snapshot) *assertionFailure {
+	for _, sc := range snapshot.ServiceChecks {
+		if sc.CheckName != sce.Name || !tagsMatch(sce.Tags, sc.Tags) {
+			continue
+		}
+		actual := strings.ToLower(sc.Status.String())
+		if actual == strings.ToLower(sce.Status) {
+			return nil
+		}
+		return &assertionFailure{
+			Kind:     fmt.Sprintf("service check %s", sce.Name),
+			Expected: sce.Status,
+			Actual:   actual,
+		}
+	}
+
+	return &assertionFailure{
+		Kind:     fmt.Sprintf("service check %s", sce.Name),
+		Expected: sce.Status,
+		Actual:   "not reported",
+	}
+}
+
+func evaluateEvent(ee eventExpectation, snapshot textformat.Snapshot) *assertionFailure {
+	for _, e := range snapshot.Events {
+		if ee.Title != "" && !strings.Contains(e.Title, ee.Title) {
+			continue
+		}
+		if ee.Text != "" && !strings.Contains(e.Text, ee.Text) {
+			continue
+		}
+		return nil
+	}
+
+	return &assertionFailure{
+		Kind:     "event",
+		Expected: fmt.Sprintf("title containing %q, text containing %q", ee.Title, ee.Text),
+		Actual:   "not reported",
+	}
+}
+
+// tagsMatch reports whether every key:value pair in want is present in got,
+// a "k:v" tag list. An empty want always matches.
+func tagsMatch(want map[string]string, got []string) bool {
+	if len(want) == 0 {
+		return true
+	}
+
+	gotSet := make(map[string]string, len(got))
+	for _, t := range got {
+		kv := strings.SplitN(t, ":", 2)
+		if len(kv) == 2 {
+			gotSet[kv[0]] = kv[1]
+		}
+	}
+
+	for k, v := range want {
+		if gotSet[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,128 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/serializer/textformat"
+)
+
+func TestTagsMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		want map[string]string
+		got  []string
+		ok   bool
+	}{
+		{"empty expectation always matches", nil, []string{"env:prod"}, true},
+		{"matching tag", map[string]string{"env": "prod"}, []string{"env:prod", "region:us"}, true},
+		{"missing tag", map[string]string{"env": "prod"}, []string{"region:us"}, false},
+		{"mismatched value", map[string]string{"env": "prod"}, []string{"env:staging"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tagsMatch(tt.want, tt.got); got != tt.ok {
+				t.Errorf("tagsMatch(%v, %v) = %v, want %v", tt.want, tt.got, got, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		comparator          string
+		actual, value, high float64
+		want                bool
+	}{
+		{"gt", 5, 1, 0, true},
+		{"gt", 1, 5, 0, false},
+		{"lt", 1, 5, 0, true},
+		{"lt", 5, 1, 0, false},
+		{"eq", 1.0, 1.0, 0, true},
+		// Floats accumulated through aggregation rarely compare bit-for-bit
+		// equal; "eq" must tolerate a tiny difference.
+		{"eq", 0.3 + 1e-12, 0.3, 0, true},
+		{"eq", 1.0, 2.0, 0, false},
+		{"between", 5, 1, 10, true},
+		{"between", 15, 1, 10, false},
+		{"unknown", 1, 1, 1, false},
+	}
+
+	for _, tt := range tests {
+		if got := compare(tt.comparator, tt.actual, tt.value, tt.high); got != tt.want {
+			t.Errorf("compare(%q, %v, %v, %v) = %v, want %v", tt.comparator, tt.actual, tt.value, tt.high, got, tt.want)
+		}
+	}
+}
+
+func snapshotWithMetric(name string, value float64, tags []string) textformat.Snapshot {
+	return textformat.Snapshot{
+		Series: metrics.Series{
+			{Name: name, Tags: tags, Points: []metrics.Point{{Ts: 0, Value: value}}},
+		},
+	}
+}
+
+func TestEvaluateMetricPasses(t *testing.T) {
+	snapshot := snapshotWithMetric("my.metric", 42, []string{"env:prod"})
+	me := metricExpectation{Name: "my.metric", Tags: map[string]string{"env": "prod"}, Comparator: "gt", Value: 10}
+
+	if f := evaluateMetric(me, snapshot); f != nil {
+		t.Fatalf("expected no failure, got %+v", f)
+	}
+}
+
+func TestEvaluateMetricNotReported(t *testing.T) {
+	snapshot := snapshotWithMetric("other.metric", 42, nil)
+	me := metricExpectation{Name: "my.metric", Comparator: "gt", Value: 10}
+
+	f := evaluateMetric(me, snapshot)
+	if f == nil || f.Actual != "not reported" {
+		t.Fatalf("expected a not-reported failure, got %+v", f)
+	}
+}
+
+func TestEvaluateMetricUnknownComparatorIsReportedDistinctly(t *testing.T) {
+	snapshot := snapshotWithMetric("my.metric", 42, nil)
+	me := metricExpectation{Name: "my.metric", Comparator: "bogus", Value: 10}
+
+	f := evaluateMetric(me, snapshot)
+	if f == nil || !strings.Contains(f.Actual, "unknown comparator") {
+		t.Fatalf("expected an unknown-comparator failure, got %+v", f)
+	}
+}
+
+func TestEvaluateEventMatchesOnTitleAndText(t *testing.T) {
+	snapshot := textformat.Snapshot{Events: metrics.Events{
+		{Title: "disk full on myhost", Text: "/var is at 100%"},
+	}}
+
+	if f := evaluateEvent(eventExpectation{Title: "disk full"}, snapshot); f != nil {
+		t.Fatalf("expected title match to pass, got %+v", f)
+	}
+	if f := evaluateEvent(eventExpectation{Text: "nonexistent"}, snapshot); f == nil {
+		t.Fatal("expected no matching event to fail")
+	}
+}
+
+func TestEvaluateExpectationsAggregatesAllFailures(t *testing.T) {
+	snapshot := snapshotWithMetric("present.metric", 1, nil)
+	exp := expectations{
+		Metrics: []metricExpectation{
+			{Name: "present.metric", Comparator: "gt", Value: 0},
+			{Name: "missing.metric", Comparator: "gt", Value: 0},
+		},
+	}
+
+	failures := evaluateExpectations(exp, snapshot)
+	if len(failures) != 1 {
+		t.Fatalf("expected exactly 1 failure, got %d: %+v", len(failures), failures)
+	}
+}
@@ -0,0 +1,151 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/DataDog/datadog-agent/cmd/agent/common"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery"
+	"github.com/DataDog/datadog-agent/pkg/collector"
+	"github.com/DataDog/datadog-agent/pkg/serializer/textformat"
+)
+
+// bundleResolvedConfig is the YAML-serializable view of a single resolved
+// check configuration (init_config + instances), as written into
+// config.yaml inside the bundle.
+type bundleResolvedConfig struct {
+	Name       string        `yaml:"name"`
+	InitConfig interface{}   `yaml:"init_config"`
+	Instances  []interface{} `yaml:"instances"`
+}
+
+// writeBundle packs everything needed to file an actionable bug report
+// about a single check into a gzipped tarball at path:
+//   - config.yaml: resolved init_config + instances for checkName
+//   - telemetry.json: the raw series/sketches/service_checks/events
+//   - status.txt: status.GetCheckStatus output for every instance run
+//   - warnings.txt: autodiscovery/loader errors and resolve warnings
+//   - agent.log: process log output captured at --log-level, if any
+func writeBundle(path string, checkName string, checkStatuses [][]byte, snapshot textformat.Snapshot, logFile string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	defer gz.Close()
+
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	if err := addBundleFile(tw, "config.yaml", resolvedConfigYAML(checkName)); err != nil {
+		return err
+	}
+	if err := addBundleFile(tw, "telemetry.json", telemetryJSON(snapshot)); err != nil {
+		return err
+	}
+	if err := addBundleFile(tw, "status.txt", bytes.Join(checkStatuses, []byte("\n"))); err != nil {
+		return err
+	}
+	if err := addBundleFile(tw, "warnings.txt", bundleWarnings(checkName)); err != nil {
+		return err
+	}
+
+	logContent := []byte("no logs captured (set --log-level and --bundle together)\n")
+	if logFile != "" {
+		if content, err := ioutil.ReadFile(logFile); err == nil {
+			logContent = content
+		}
+	}
+	if err := addBundleFile(tw, "agent.log", logContent); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func addBundleFile(tw *tar.Writer, name string, content []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
+
+func resolvedConfigYAML(checkName string) []byte {
+	var configs []bundleResolvedConfig
+	for _, c := range common.AC.GetAllConfigs() {
+		if c.Name != checkName {
+			continue
+		}
+		cfg := bundleResolvedConfig{Name: c.Name, InitConfig: string(c.InitConfig)}
+		for _, inst := range c.Instances {
+			cfg.Instances = append(cfg.Instances, string(inst))
+		}
+		configs = append(configs, cfg)
+	}
+
+	out, err := yaml.Marshal(configs)
+	if err != nil {
+		return []byte(fmt.Sprintf("error marshaling resolved config: %v\n", err))
+	}
+	return out
+}
+
+// telemetryJSON renders telemetry.json using the same textformat.JSONFormatter
+// as `--format json`, so the two agree on key names (metrics, sketches,
+// service_checks, events) instead of drifting into Go's capitalized field
+// names. snapshot must be captured before writeBundle is called, since the
+// aggregator's Get* accessors drain it on every read.
+func telemetryJSON(snapshot textformat.Snapshot) []byte {
+	out, err := textformat.JSONFormatter{}.Format(snapshot, textformat.DefaultFilter())
+	if err != nil {
+		return []byte(fmt.Sprintf("error marshaling telemetry: %v\n", err))
+	}
+	return []byte(out)
+}
+
+func bundleWarnings(checkName string) []byte {
+	var b bytes.Buffer
+
+	for check, errs := range autodiscovery.GetConfigErrors() {
+		if check == checkName {
+			fmt.Fprintf(&b, "config error: %s\n", errs)
+		}
+	}
+	for check, errs := range collector.GetLoaderErrors() {
+		if check == checkName {
+			for loader, err := range errs {
+				fmt.Fprintf(&b, "loader error (%s): %s\n", loader, err)
+			}
+		}
+	}
+	for check, warnings := range autodiscovery.GetResolveWarnings() {
+		if check == checkName {
+			for _, w := range warnings {
+				fmt.Fprintf(&b, "resolve warning: %s\n", w)
+			}
+		}
+	}
+
+	return b.Bytes()
+}
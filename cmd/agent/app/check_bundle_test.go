@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+)
+
+func TestAddBundleFileWritesNameAndContent(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	if err := addBundleFile(tw, "status.txt", []byte("ok\n")); err != nil {
+		t.Fatalf("addBundleFile returned an error: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar.Writer.Close returned an error: %v", err)
+	}
+
+	tr := tar.NewReader(&buf)
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatalf("expected one tar entry, got error: %v", err)
+	}
+	if hdr.Name != "status.txt" {
+		t.Errorf("tar entry name = %q, want %q", hdr.Name, "status.txt")
+	}
+
+	content, err := ioutil.ReadAll(tr)
+	if err != nil {
+		t.Fatalf("could not read tar entry content: %v", err)
+	}
+	if string(content) != "ok\n" {
+		t.Errorf("tar entry content = %q, want %q", content, "ok\n")
+	}
+}
+
+// TestWriteBundleLayout checks that writeBundle produces a gzipped tarball
+// containing exactly the five files documented on writeBundle, regardless
+// of whether the check being bundled actually exists or produced telemetry.
+func TestWriteBundleLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-bundle-test")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+
+	path := dir + "/bundle.tar.gz"
+	snapshot := snapshotWithMetric("my.metric", 1, nil)
+
+	if err := writeBundle(path, "nonexistent-check", [][]byte{[]byte("check status")}, snapshot, ""); err != nil {
+		t.Fatalf("writeBundle returned an error: %v", err)
+	}
+
+	f, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read bundle at %s: %v", path, err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(f))
+	if err != nil {
+		t.Fatalf("bundle is not valid gzip: %v", err)
+	}
+
+	want := map[string]bool{
+		"config.yaml":    false,
+		"telemetry.json": false,
+		"status.txt":     false,
+		"warnings.txt":   false,
+		"agent.log":      false,
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if _, ok := want[hdr.Name]; !ok {
+			t.Errorf("unexpected file %q in bundle", hdr.Name)
+			continue
+		}
+		want[hdr.Name] = true
+	}
+
+	for name, seen := range want {
+		if !seen {
+			t.Errorf("bundle is missing %q", name)
+		}
+	}
+}
+
+func TestResolvedConfigYAMLWithNoMatchingCheck(t *testing.T) {
+	out := resolvedConfigYAML("no-such-check-in-this-test")
+	if string(out) != "[]\n" {
+		t.Errorf("resolvedConfigYAML for an unconfigured check = %q, want an empty list", out)
+	}
+}
+
+func TestBundleWarningsWithNoMatchingCheck(t *testing.T) {
+	out := bundleWarnings("no-such-check-in-this-test")
+	if len(out) != 0 {
+		t.Errorf("bundleWarnings for an unconfigured check = %q, want empty", out)
+	}
+}
@@ -0,0 +1,148 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	"github.com/DataDog/datadog-agent/pkg/status"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// scheduleMinJitter and scheduleMaxJitter bound the random initial delay
+// applied before the first scheduled run, to avoid a thundering herd of
+// agents all hitting --interval-url (and any downstream integration) at
+// the same instant on startup.
+const (
+	scheduleMinJitter = 14 * time.Second
+	scheduleMaxJitter = 22 * time.Second
+)
+
+// runScheduledChecks runs each of cs on a loop, honoring --interval and
+// --interval-url, until the process receives an interrupt or term signal.
+func runScheduledChecks(cs []check.Check, agg *aggregator.BufferedAggregator) error {
+	interval, err := time.ParseDuration(checkInterval)
+	if err != nil {
+		return fmt.Errorf("invalid --interval %q: %v", checkInterval, err)
+	}
+
+	jitter := scheduleMinJitter + time.Duration(rand.Int63n(int64(scheduleMaxJitter-scheduleMinJitter)))
+	fmt.Printf("Waiting %s before the first scheduled run to avoid a thundering herd...\n", jitter)
+	time.Sleep(jitter)
+
+	// A signal is delivered to exactly one receiver on a channel, not
+	// broadcast, so one shared stop channel can't be selected on by every
+	// per-check goroutine below. Use a context instead: one goroutine reads
+	// the signal and cancels it, and every loop selects on ctx.Done().
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	var wg sync.WaitGroup
+	for _, c := range cs {
+		wg.Add(1)
+		go func(c check.Check) {
+			defer wg.Done()
+			scheduleCheckLoop(ctx, c, agg, interval)
+		}(c)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// scheduleCheckLoop repeatedly runs c every interval (refreshed from
+// --interval-url, if set, between iterations) until ctx is canceled. Each
+// check has exactly one dedicated goroutine running this loop (see
+// runScheduledChecks), and the next iteration only starts once the current
+// one has returned and the interval has elapsed, so overlapping runs of the
+// same check are structurally impossible without extra locking here.
+func scheduleCheckLoop(ctx context.Context, c check.Check, agg *aggregator.BufferedAggregator, interval time.Duration) {
+	for {
+		interval = refreshInterval(c.ID(), interval)
+		runScheduledOnce(c, agg)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// scheduleAggMu serializes run+collect+print across every scheduled check.
+// All scheduled checks share the single aggregator built in checkCmd, which
+// has no notion of "this batch belongs to check A"; without this lock, two
+// checks' iterations overlapping would let one check's printed output
+// silently include or steal the other's metrics.
+var scheduleAggMu sync.Mutex
+
+func runScheduledOnce(c check.Check, agg *aggregator.BufferedAggregator) {
+	scheduleAggMu.Lock()
+	defer scheduleAggMu.Unlock()
+
+	s := runCheck(c, agg)
+	time.Sleep(time.Duration(checkDelay) * time.Millisecond)
+	printMetrics(buildSnapshot(agg))
+	checkStatus, _ := status.GetCheckStatus(c, s)
+	fmt.Println(string(checkStatus))
+}
+
+// refreshInterval polls --interval-url, if configured, for an updated
+// interval to use for the given check ID. On any error it logs a warning
+// and falls back to the previously known interval.
+func refreshInterval(id check.ID, fallback time.Duration) time.Duration {
+	if checkIntervalURL == "" {
+		return fallback
+	}
+
+	d, err := fetchInterval(checkIntervalURL, id)
+	if err != nil {
+		log.Warnf("could not refresh interval for %s from %s: %v", id, checkIntervalURL, err)
+		return fallback
+	}
+
+	return d
+}
+
+type intervalResponse struct {
+	Interval string `json:"interval"`
+}
+
+func fetchInterval(url string, id check.ID) (time.Duration, error) {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("%s?check_id=%s", url, id))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var ir intervalResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ir); err != nil {
+		return 0, err
+	}
+
+	return time.ParseDuration(ir.Interval)
+}
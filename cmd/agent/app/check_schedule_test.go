@@ -0,0 +1,104 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+)
+
+func TestFetchIntervalParsesDuration(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("check_id"); got != "my-check:123" {
+			t.Errorf("request check_id = %q, want %q", got, "my-check:123")
+		}
+		w.Write([]byte(`{"interval": "45s"}`))
+	}))
+	defer srv.Close()
+
+	got, err := fetchInterval(srv.URL, check.ID("my-check:123"))
+	if err != nil {
+		t.Fatalf("fetchInterval returned an error: %v", err)
+	}
+	if got != 45*time.Second {
+		t.Errorf("fetchInterval = %v, want 45s", got)
+	}
+}
+
+func TestFetchIntervalRejectsNonOKStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if _, err := fetchInterval(srv.URL, check.ID("my-check:123")); err == nil {
+		t.Fatal("expected an error for a non-200 response")
+	}
+}
+
+func TestFetchIntervalRejectsMalformedJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer srv.Close()
+
+	if _, err := fetchInterval(srv.URL, check.ID("my-check:123")); err == nil {
+		t.Fatal("expected an error for malformed JSON")
+	}
+}
+
+func TestFetchIntervalRejectsMalformedDuration(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"interval": "not-a-duration"}`))
+	}))
+	defer srv.Close()
+
+	if _, err := fetchInterval(srv.URL, check.ID("my-check:123")); err == nil {
+		t.Fatal("expected an error for a malformed interval duration")
+	}
+}
+
+func TestRefreshIntervalFallsBackWithoutIntervalURL(t *testing.T) {
+	checkIntervalURL = ""
+	got := refreshInterval(check.ID("my-check:123"), 30*time.Second)
+	if got != 30*time.Second {
+		t.Errorf("refreshInterval with no --interval-url = %v, want the fallback 30s", got)
+	}
+}
+
+func TestRefreshIntervalFallsBackOnHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	checkIntervalURL = srv.URL
+	defer func() { checkIntervalURL = "" }()
+
+	got := refreshInterval(check.ID("my-check:123"), 30*time.Second)
+	if got != 30*time.Second {
+		t.Errorf("refreshInterval on HTTP error = %v, want the fallback 30s", got)
+	}
+}
+
+func TestRefreshIntervalUsesFetchedValue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"interval": "90s"}`))
+	}))
+	defer srv.Close()
+
+	checkIntervalURL = srv.URL
+	defer func() { checkIntervalURL = "" }()
+
+	got := refreshInterval(check.ID("my-check:123"), 30*time.Second)
+	if got != 90*time.Second {
+		t.Errorf("refreshInterval = %v, want the fetched 90s", got)
+	}
+}
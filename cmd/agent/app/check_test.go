@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package app
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/serializer/textformat"
+)
+
+func TestParseOutputFilterDefaultsToEverything(t *testing.T) {
+	if got := parseOutputFilter(""); got != textformat.DefaultFilter() {
+		t.Errorf("expected empty --output-filter to render everything, got %+v", got)
+	}
+}
+
+func TestParseOutputFilterSingleType(t *testing.T) {
+	got := parseOutputFilter("metrics")
+	want := textformat.Filter{Metrics: true}
+	if got != want {
+		t.Errorf("parseOutputFilter(%q) = %+v, want %+v", "metrics", got, want)
+	}
+}
+
+func TestParseOutputFilterMultipleTypesWithSpaces(t *testing.T) {
+	got := parseOutputFilter("metrics, events")
+	want := textformat.Filter{Metrics: true, Events: true}
+	if got != want {
+		t.Errorf("parseOutputFilter with spaces = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseOutputFilterIgnoresUnknownTypes(t *testing.T) {
+	got := parseOutputFilter("metrics,bogus")
+	want := textformat.Filter{Metrics: true}
+	if got != want {
+		t.Errorf("parseOutputFilter should ignore unknown entries, got %+v, want %+v", got, want)
+	}
+}
+
+// TestPrintMetricsThenAssertSeeSameRun is an end-to-end check of the RunE
+// flow's contract: a single textformat.Snapshot, built once from a check
+// run, must be reusable by both printMetrics and runAssertions. Before this
+// was fixed, printMetrics and the --assert-file path each re-derived their
+// own snapshot from the aggregator, and since the aggregator's Get*
+// accessors drain it on every call, the second read always came back empty.
+func TestPrintMetricsThenAssertSeeSameRun(t *testing.T) {
+	snapshot := textformat.Snapshot{
+		Series: metrics.Series{
+			{Name: "my.metric", Tags: []string{"env:prod"}, Points: []metrics.Point{{Ts: 0, Value: 42}}},
+		},
+	}
+
+	// Simulates the per-check loop in checkCmd: print the run's telemetry...
+	printMetrics(snapshot)
+
+	// ...then evaluate --assert-file expectations against that very same
+	// snapshot, exactly as runAssertions does. If snapshot had already been
+	// drained by printMetrics, this would fail with "not reported".
+	exp := expectations{
+		Metrics: []metricExpectation{
+			{Name: "my.metric", Tags: map[string]string{"env": "prod"}, Comparator: "eq", Value: 42},
+		},
+	}
+	if failures := evaluateExpectations(exp, snapshot); len(failures) != 0 {
+		t.Fatalf("expected the same-run snapshot to satisfy its own assertions, got failures: %+v", failures)
+	}
+}
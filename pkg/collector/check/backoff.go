@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019 Datadog, Inc.
+
+package check
+
+import (
+	"math/rand"
+	"time"
+
+	log "github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// BackoffConfig controls the retry behavior of RunWithBackoff.
+type BackoffConfig struct {
+	// MaxRetries is the number of additional attempts after the first one.
+	MaxRetries int
+	// Base is the base delay; attempt n sleeps roughly Base * 2^n plus jitter.
+	Base time.Duration
+	// MaxElapsed bounds the total time spent retrying. Zero means no bound.
+	MaxElapsed time.Duration
+}
+
+// RunWithBackoff runs c.Run(), retrying with exponential backoff and jitter
+// on failure until an attempt succeeds, MaxRetries is exhausted, or
+// MaxElapsed has passed. It returns the error of the last attempt, or nil if
+// some attempt succeeded. Each attempt is logged with its number, duration
+// and error, to help diagnose flaky external systems.
+func RunWithBackoff(c Check, cfg BackoffConfig) error {
+	start := time.Now()
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		attemptStart := time.Now()
+		err = c.Run()
+		elapsed := time.Since(attemptStart)
+
+		if err == nil {
+			log.Infof("check %s: attempt %d succeeded after %s", c.String(), attempt+1, elapsed)
+			return nil
+		}
+
+		log.Warnf("check %s: attempt %d failed after %s (total elapsed %s): %v", c.String(), attempt+1, elapsed, time.Since(start), err)
+
+		if attempt >= cfg.MaxRetries {
+			return err
+		}
+
+		delay := backoffDelay(cfg.Base, attempt)
+		if cfg.MaxElapsed > 0 {
+			remaining := cfg.MaxElapsed - time.Since(start)
+			if remaining <= 0 {
+				return err
+			}
+			if delay > remaining {
+				delay = remaining
+			}
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+// maxBackoffDelay caps the computed delay between retries. Without a cap, a
+// large --retry with the default unbounded --retry-max-elapsed would shift
+// base past time.Duration's int64 range, wrapping to a negative or
+// near-zero duration and turning the backoff into a tight busy-retry loop.
+const maxBackoffDelay = 5 * time.Minute
+
+// backoffDelay returns Base * 2^attempt, capped at maxBackoffDelay, plus up
+// to Base of jitter.
+func backoffDelay(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	// Cap the shift count at 62. base << attempt can still wrap around
+	// int64 for large attempts; the check below replaces a non-positive or
+	// oversized result with maxBackoffDelay.
+	if attempt > 62 {
+		attempt = 62
+	}
+
+	delay := base << uint(attempt)
+	if delay <= 0 || delay > maxBackoffDelay {
+		delay = maxBackoffDelay
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(base)))
+}
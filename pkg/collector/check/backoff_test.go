@@ -0,0 +1,130 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019 Datadog, Inc.
+
+package check
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeCheck embeds the (nil) Check interface so it satisfies Check without
+// having to stub out every method; only Run and String are actually called
+// by RunWithBackoff.
+type fakeCheck struct {
+	Check
+	name string
+	run  func() error
+}
+
+func (f *fakeCheck) Run() error     { return f.run() }
+func (f *fakeCheck) String() string { return f.name }
+
+func TestBackoffDelayCapsLargeAttempts(t *testing.T) {
+	// Before the cap, base << attempt overflowed int64 for large attempts
+	// and could come back negative or near-zero.
+	for _, attempt := range []int{40, 62, 63, 1000} {
+		d := backoffDelay(time.Second, attempt)
+		if d <= 0 {
+			t.Fatalf("attempt %d: expected a positive capped delay, got %s", attempt, d)
+		}
+		if d > maxBackoffDelay+time.Second {
+			t.Fatalf("attempt %d: delay %s exceeds maxBackoffDelay %s (plus jitter)", attempt, d, maxBackoffDelay)
+		}
+	}
+}
+
+func TestBackoffDelayGrowsExponentiallyBeforeCap(t *testing.T) {
+	d0 := backoffDelay(time.Second, 0)
+	d1 := backoffDelay(time.Second, 1)
+	// d1 should be roughly double d0 (base*2^1 vs base*2^0), allowing for
+	// up to one base's worth of jitter on each side.
+	if d0 < time.Second || d0 >= 2*time.Second || d1 < 2*time.Second || d1 >= 3*time.Second {
+		t.Fatalf("expected d0 in [1s, 2s) and d1 in [2s, 3s), got d0=%s d1=%s", d0, d1)
+	}
+}
+
+func TestBackoffDelayZeroBase(t *testing.T) {
+	if d := backoffDelay(0, 5); d != 0 {
+		t.Fatalf("expected zero delay for zero base, got %s", d)
+	}
+}
+
+func TestRunWithBackoffSucceedsWithoutRetrying(t *testing.T) {
+	calls := 0
+	c := &fakeCheck{name: "mycheck", run: func() error {
+		calls++
+		return nil
+	}}
+
+	if err := RunWithBackoff(c, BackoffConfig{MaxRetries: 3, Base: time.Millisecond}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected exactly 1 call, got %d", calls)
+	}
+}
+
+func TestRunWithBackoffRetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	c := &fakeCheck{name: "mycheck", run: func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient failure")
+		}
+		return nil
+	}}
+
+	if err := RunWithBackoff(c, BackoffConfig{MaxRetries: 5, Base: time.Millisecond}); err != nil {
+		t.Fatalf("expected eventual success, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls before success, got %d", calls)
+	}
+}
+
+func TestRunWithBackoffReturnsLastErrorAfterMaxRetries(t *testing.T) {
+	calls := 0
+	wantErr := errors.New("persistent failure")
+	c := &fakeCheck{name: "mycheck", run: func() error {
+		calls++
+		return wantErr
+	}}
+
+	err := RunWithBackoff(c, BackoffConfig{MaxRetries: 2, Base: time.Millisecond})
+	if err != wantErr {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 1 initial attempt + 2 retries = 3 calls, got %d", calls)
+	}
+}
+
+func TestRunWithBackoffStopsAtMaxElapsed(t *testing.T) {
+	calls := 0
+	c := &fakeCheck{name: "mycheck", run: func() error {
+		calls++
+		return errors.New("always fails")
+	}}
+
+	start := time.Now()
+	err := RunWithBackoff(c, BackoffConfig{
+		MaxRetries: 1000,
+		Base:       20 * time.Millisecond,
+		MaxElapsed: 50 * time.Millisecond,
+	})
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected an error once MaxElapsed is exceeded")
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("expected RunWithBackoff to respect MaxElapsed, took %s", elapsed)
+	}
+	if calls < 2 {
+		t.Fatalf("expected at least one retry before giving up, got %d calls", calls)
+	}
+}
@@ -1,27 +1,62 @@
 // Unless explicitly stated otherwise all files in this repository are licensed
 // under the Apache License Version 2.0.
 // This product includes software developed at Datadog (https://www.datadoghq.com/).
-// Copyright 2018 Datadog, Inc.
+// Copyright 2018-2019 Datadog, Inc.
 
 package check
 
 import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
 	"fmt"
 	"hash/fnv"
 	"strings"
 
+	yaml "gopkg.in/yaml.v2"
+
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
 )
 
 // ID is the representation of the unique ID of a Check instance
 type ID string
 
-// Identify returns an unique ID for a check and its configuration
-func Identify(check Check, instance integration.Data, initConfig integration.Data, extraID string) ID {
-	return BuildID(check.String(), instance, initConfig, extraID)
+// IDVersion selects which BuildID algorithm Identify uses.
+type IDVersion int
+
+const (
+	// IDVersion1 is the legacy FNV-64 scheme, see BuildID.
+	IDVersion1 IDVersion = iota
+	// IDVersion2 is the canonicalized SHA-256 scheme, see BuildIDv2.
+	IDVersion2
+)
+
+// IdentifyOptions controls Identify's choice of ID scheme. The zero value
+// selects IDVersion1, preserving pre-existing behavior.
+type IdentifyOptions struct {
+	Version IDVersion
+	// ExtraID is folded into IDVersion1 IDs, same as BuildID's extraID.
+	ExtraID string
+	// Source is folded into IDVersion2 IDs, same as BuildIDv2's source.
+	Source string
+}
+
+// Identify returns a unique ID for a check and its configuration, using the
+// scheme selected by opts.
+func Identify(check Check, instance integration.Data, initConfig integration.Data, opts IdentifyOptions) ID {
+	if opts.Version == IDVersion2 {
+		return BuildIDv2(check.String(), instance, initConfig, opts.Source)
+	}
+	return BuildID(check.String(), instance, initConfig, opts.ExtraID)
 }
 
-// BuildID returns an unique ID for a check name and its configuration
+// BuildID returns an unique ID for a check name and its configuration.
+//
+// This is the legacy (v1) scheme: it hashes instance and initConfig as-is
+// with FNV-64, so two equivalent configs serialized with a different key
+// order hash differently, and the 64-bit digest can collide in large
+// fleets. Prefer BuildIDv2 for new call sites; this is kept for the IDs
+// already persisted/compared across the fleet.
 func BuildID(checkName string, instance, initConfig integration.Data, extraID string) ID {
 	h := fnv.New64()
 	h.Write([]byte(instance))
@@ -34,6 +69,111 @@ func BuildID(checkName string, instance, initConfig integration.Data, extraID st
 	return ID(fmt.Sprintf("%s:%x", checkName, h.Sum64()))
 }
 
+// BuildIDv2 returns a versioned, collision-resistant ID for a check name,
+// its configuration and the source that produced it. Unlike BuildID, it:
+//
+//   - canonicalizes instance and initConfig (sorting map keys and
+//     normalizing scalars) before hashing, so equivalent YAML/JSON with a
+//     different key order produces the same ID;
+//   - hashes with SHA-256 truncated to 128 bits, rendered as base32, making
+//     accidental collisions across large fleets practically impossible;
+//   - folds in a caller-supplied source (e.g. an autodiscovery provider
+//     name), so identical configs surfaced by different providers don't
+//     alias to the same ID.
+func BuildIDv2(checkName string, instance, initConfig integration.Data, source string) ID {
+	h := sha256.New()
+	h.Write(canonicalize(instance))
+	h.Write(canonicalize(initConfig))
+
+	digest := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(h.Sum(nil)[:16])
+
+	if source != "" {
+		return ID(fmt.Sprintf("%s:%s:v2:%s", checkName, source, digest))
+	}
+	return ID(fmt.Sprintf("%s:v2:%s", checkName, digest))
+}
+
+// canonicalize renders raw YAML/JSON config bytes into a deterministic byte
+// stream: keys are sorted and scalars normalized, so semantically
+// equivalent configs always produce the same bytes regardless of how they
+// were originally ordered or serialized. Invalid YAML is hashed verbatim,
+// so callers never fail to build an ID over malformed config.
+func canonicalize(raw integration.Data) []byte {
+	var v interface{}
+	if err := yaml.Unmarshal(raw, &v); err != nil {
+		return raw
+	}
+
+	// encoding/json sorts map[string]interface{} keys, which is what gives
+	// us the canonical ordering; yaml.v2 decodes mappings into
+	// map[interface{}]interface{}, so normalize those to string-keyed maps.
+	canonical, err := json.Marshal(normalizeYAML(v))
+	if err != nil {
+		return raw
+	}
+
+	return canonical
+}
+
+func normalizeYAML(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[fmt.Sprintf("%v", k)] = normalizeYAML(item)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[k] = normalizeYAML(item)
+		}
+		return m
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = normalizeYAML(item)
+		}
+		return out
+	default:
+		return val
+	}
+}
+
+// ParsedID is the structured decomposition of an ID produced by BuildID or
+// BuildIDv2.
+type ParsedID struct {
+	Name    string
+	Source  string
+	ExtraID string
+	Digest  string
+	Version IDVersion
+}
+
+// ParseID decomposes id back into its components. It understands both the
+// legacy "name:digest" / "name:extraID:digest" scheme produced by BuildID
+// and the "name:source:v2:digest" / "name:v2:digest" scheme produced by
+// BuildIDv2.
+func ParseID(id ID) ParsedID {
+	parts := strings.Split(string(id), ":")
+
+	switch len(parts) {
+	case 4:
+		if parts[2] == "v2" {
+			return ParsedID{Name: parts[0], Source: parts[1], Digest: parts[3], Version: IDVersion2}
+		}
+	case 3:
+		if parts[1] == "v2" {
+			return ParsedID{Name: parts[0], Digest: parts[2], Version: IDVersion2}
+		}
+		return ParsedID{Name: parts[0], ExtraID: parts[1], Digest: parts[2], Version: IDVersion1}
+	case 2:
+		return ParsedID{Name: parts[0], Digest: parts[1], Version: IDVersion1}
+	}
+
+	return ParsedID{Name: string(id), Version: IDVersion1}
+}
+
 // IDToCheckName returns the check name from a check ID
 func IDToCheckName(id ID) string {
 	return strings.SplitN(string(id), ":", 2)[0]
@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018-2019 Datadog, Inc.
+
+package check
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+)
+
+func TestBuildIDIsOrderSensitive(t *testing.T) {
+	a := integration.Data(`{"foo": 1, "bar": 2}`)
+	b := integration.Data(`{"bar": 2, "foo": 1}`)
+
+	if BuildID("mycheck", a, integration.Data(`{}`), "") == BuildID("mycheck", b, integration.Data(`{}`), "") {
+		t.Fatalf("expected legacy BuildID to distinguish differently-ordered equivalent configs")
+	}
+}
+
+func TestBuildIDv2CanonicalizesKeyOrder(t *testing.T) {
+	a := integration.Data(`{"foo": 1, "bar": 2, "nested": {"x": 1, "y": 2}}`)
+	b := integration.Data(`{"nested": {"y": 2, "x": 1}, "bar": 2, "foo": 1}`)
+
+	id1 := BuildIDv2("mycheck", a, integration.Data(`{}`), "")
+	id2 := BuildIDv2("mycheck", b, integration.Data(`{}`), "")
+	if id1 != id2 {
+		t.Fatalf("expected BuildIDv2 to be order-insensitive, got %s != %s", id1, id2)
+	}
+}
+
+func TestBuildIDv2StableAcrossRepeatedRuns(t *testing.T) {
+	instance := integration.Data(`{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}`)
+	initConfig := integration.Data(`{}`)
+
+	first := BuildIDv2("mycheck", instance, initConfig, "provider-a")
+	for i := 0; i < 50; i++ {
+		if got := BuildIDv2("mycheck", instance, initConfig, "provider-a"); got != first {
+			t.Fatalf("BuildIDv2 not stable across repeated calls (likely map iteration order): %s != %s", got, first)
+		}
+	}
+}
+
+func TestBuildIDv2FoldsInSource(t *testing.T) {
+	instance := integration.Data(`{"a": 1}`)
+	initConfig := integration.Data(`{}`)
+
+	if BuildIDv2("mycheck", instance, initConfig, "provider-a") == BuildIDv2("mycheck", instance, initConfig, "provider-b") {
+		t.Fatalf("expected different sources to produce different IDs")
+	}
+}
+
+func TestParseID(t *testing.T) {
+	legacy := BuildID("mycheck", integration.Data(`{}`), integration.Data(`{}`), "extra")
+	parsed := ParseID(legacy)
+	if parsed.Name != "mycheck" || parsed.ExtraID != "extra" || parsed.Version != IDVersion1 {
+		t.Fatalf("unexpected parse of legacy ID: %+v", parsed)
+	}
+
+	v2 := BuildIDv2("mycheck", integration.Data(`{}`), integration.Data(`{}`), "ad-provider")
+	parsed = ParseID(v2)
+	if parsed.Name != "mycheck" || parsed.Source != "ad-provider" || parsed.Version != IDVersion2 {
+		t.Fatalf("unexpected parse of v2 ID: %+v", parsed)
+	}
+}
@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package textformat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InfluxFormatter renders metrics and service checks as InfluxDB line
+// protocol. Sketches and events have no natural single-value line-protocol
+// representation and are omitted.
+type InfluxFormatter struct{}
+
+// Format implements PrintFormatter.
+func (InfluxFormatter) Format(snapshot Snapshot, filter Filter) (string, error) {
+	var b strings.Builder
+
+	if filter.Metrics {
+		for _, serie := range snapshot.Series {
+			measurement := influxEscapeKey(serie.Name)
+			tags := influxTags(serie.Tags, serie.Host)
+			for _, p := range serie.Points {
+				fmt.Fprintf(&b, "%s%s value=%v %d\n", measurement, tags, p.Value, int64(p.Ts))
+			}
+		}
+	}
+
+	if filter.ServiceChecks {
+		for _, sc := range snapshot.ServiceChecks {
+			measurement := influxEscapeKey(sc.CheckName) + ".service_check"
+			tags := influxTags(sc.Tags, sc.Host)
+			fmt.Fprintf(&b, "%s%s status=%di %d\n", measurement, tags, int(sc.Status), sc.Ts)
+		}
+	}
+
+	return b.String(), nil
+}
+
+func influxTags(tags []string, host string) string {
+	var b strings.Builder
+	if host != "" {
+		fmt.Fprintf(&b, ",host=%s", influxEscapeTagValue(host))
+	}
+	for _, t := range tags {
+		kv := strings.SplitN(t, ":", 2)
+		if len(kv) != 2 {
+			fmt.Fprintf(&b, ",%s=true", influxEscapeKey(kv[0]))
+			continue
+		}
+		fmt.Fprintf(&b, ",%s=%s", influxEscapeKey(kv[0]), influxEscapeTagValue(kv[1]))
+	}
+	return b.String()
+}
+
+func influxEscapeKey(s string) string {
+	r := strings.NewReplacer(" ", "\\ ", ",", "\\,", "=", "\\=")
+	return r.Replace(s)
+}
+
+func influxEscapeTagValue(s string) string {
+	return influxEscapeKey(s)
+}
@@ -0,0 +1,37 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package textformat
+
+import "encoding/json"
+
+// JSONFormatter renders a Snapshot as a single indented JSON object keyed by
+// telemetry type, e.g. `agent check --format json`.
+type JSONFormatter struct{}
+
+// Format implements PrintFormatter.
+func (JSONFormatter) Format(snapshot Snapshot, filter Filter) (string, error) {
+	out := make(map[string]interface{})
+
+	if filter.Metrics && len(snapshot.Series) != 0 {
+		out["metrics"] = snapshot.Series
+	}
+	if filter.Sketches && len(snapshot.Sketches) != 0 {
+		out["sketches"] = snapshot.Sketches
+	}
+	if filter.ServiceChecks && len(snapshot.ServiceChecks) != 0 {
+		out["service_checks"] = snapshot.ServiceChecks
+	}
+	if filter.Events && len(snapshot.Events) != 0 {
+		out["events"] = snapshot.Events
+	}
+
+	j, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(j) + "\n", nil
+}
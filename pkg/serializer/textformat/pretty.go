@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package textformat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// PrettyFormatter renders each telemetry type as a colored, indented-JSON
+// section. This is the historical default output of `agent check`.
+type PrettyFormatter struct{}
+
+// Format implements PrintFormatter.
+func (PrettyFormatter) Format(snapshot Snapshot, filter Filter) (string, error) {
+	var b strings.Builder
+
+	section := func(title string, v interface{}) error {
+		j, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "=== %s ===\n", color.BlueString(title))
+		b.Write(j)
+		b.WriteString("\n")
+		return nil
+	}
+
+	if filter.Metrics && len(snapshot.Series) != 0 {
+		if err := section("Series", snapshot.Series); err != nil {
+			return "", err
+		}
+	}
+	if filter.Sketches && len(snapshot.Sketches) != 0 {
+		if err := section("Sketches", snapshot.Sketches); err != nil {
+			return "", err
+		}
+	}
+	if filter.ServiceChecks && len(snapshot.ServiceChecks) != 0 {
+		if err := section("Service Checks", snapshot.ServiceChecks); err != nil {
+			return "", err
+		}
+	}
+	if filter.Events && len(snapshot.Events) != 0 {
+		if err := section("Events", snapshot.Events); err != nil {
+			return "", err
+		}
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package textformat
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PrometheusFormatter renders metrics and service checks as Prometheus text
+// exposition format. Sketches and events don't map to a single sample value
+// and are omitted.
+type PrometheusFormatter struct{}
+
+var promNameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Format implements PrintFormatter.
+func (PrometheusFormatter) Format(snapshot Snapshot, filter Filter) (string, error) {
+	var b strings.Builder
+
+	if filter.Metrics {
+		for _, serie := range snapshot.Series {
+			name := promMetricName(serie.Name)
+			labels := promLabels(serie.Tags, serie.Host)
+			for _, p := range serie.Points {
+				fmt.Fprintf(&b, "%s%s %v %d\n", name, labels, p.Value, int64(p.Ts*1000))
+			}
+		}
+	}
+
+	if filter.ServiceChecks {
+		for _, sc := range snapshot.ServiceChecks {
+			name := promMetricName(sc.CheckName) + "_service_check"
+			labels := promLabels(sc.Tags, sc.Host)
+			fmt.Fprintf(&b, "%s%s %d %d\n", name, labels, int(sc.Status), sc.Ts*1000)
+		}
+	}
+
+	return b.String(), nil
+}
+
+func promMetricName(name string) string {
+	return promNameSanitizer.ReplaceAllString(name, "_")
+}
+
+func promLabels(tags []string, host string) string {
+	labels := make([]string, 0, len(tags)+1)
+	if host != "" {
+		labels = append(labels, fmt.Sprintf(`host=%q`, host))
+	}
+	for _, t := range tags {
+		kv := strings.SplitN(t, ":", 2)
+		if len(kv) != 2 {
+			labels = append(labels, fmt.Sprintf(`%s="true"`, promMetricName(kv[0])))
+			continue
+		}
+		labels = append(labels, fmt.Sprintf("%s=%q", promMetricName(kv[0]), kv[1]))
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
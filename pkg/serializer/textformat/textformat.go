@@ -0,0 +1,61 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+// Package textformat renders a one-off snapshot of aggregator telemetry
+// (series, sketches, service checks and events) into various text formats.
+// It backs the `--format` flag of `agent check`, but is kept independent of
+// the CLI so other one-off telemetry dumps can reuse it.
+package textformat
+
+import (
+	"fmt"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+// Snapshot is the telemetry collected by a single aggregator flush.
+type Snapshot struct {
+	Series        metrics.Series
+	Sketches      metrics.SketchSeriesList
+	ServiceChecks metrics.ServiceChecks
+	Events        metrics.Events
+}
+
+// Filter selects which parts of a Snapshot a PrintFormatter should render,
+// mirroring the `--output-filter` flag.
+type Filter struct {
+	Metrics       bool
+	Sketches      bool
+	ServiceChecks bool
+	Events        bool
+}
+
+// DefaultFilter renders every telemetry type, matching the behavior of
+// `agent check` before `--output-filter` existed.
+func DefaultFilter() Filter {
+	return Filter{Metrics: true, Sketches: true, ServiceChecks: true, Events: true}
+}
+
+// PrintFormatter renders a Snapshot, restricted to filter, as text.
+type PrintFormatter interface {
+	// Format returns the rendered representation of snapshot.
+	Format(snapshot Snapshot, filter Filter) (string, error)
+}
+
+var formatters = map[string]PrintFormatter{
+	"json":       JSONFormatter{},
+	"pretty":     PrettyFormatter{},
+	"influx":     InfluxFormatter{},
+	"prometheus": PrometheusFormatter{},
+}
+
+// ForName returns the PrintFormatter registered under name.
+func ForName(name string) (PrintFormatter, error) {
+	f, ok := formatters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q, expected one of: json, pretty, influx, prometheus", name)
+	}
+	return f, nil
+}
@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2019 Datadog, Inc.
+
+package textformat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+func sampleSnapshot() Snapshot {
+	return Snapshot{
+		Series: metrics.Series{
+			{
+				Name:   "system.cpu.idle",
+				Host:   "myhost",
+				Tags:   []string{"env:prod"},
+				Points: []metrics.Point{{Ts: 1500000000, Value: 42.5}},
+			},
+		},
+		ServiceChecks: metrics.ServiceChecks{
+			{
+				CheckName: "myservice.can_connect",
+				Host:      "myhost",
+				Tags:      []string{"env:prod"},
+				Status:    metrics.ServiceCheckStatus(0),
+				Ts:        1500000000,
+			},
+		},
+		Events: metrics.Events{
+			{
+				Title: "something happened",
+				Text:  "details here",
+			},
+		},
+	}
+}
+
+func TestForName(t *testing.T) {
+	for _, name := range []string{"json", "pretty", "influx", "prometheus"} {
+		if _, err := ForName(name); err != nil {
+			t.Errorf("expected %q to be a known format, got error: %v", name, err)
+		}
+	}
+
+	if _, err := ForName("xml"); err == nil {
+		t.Error("expected an unknown format to return an error")
+	}
+}
+
+func TestJSONFormatterUsesSnakeCaseKeysAndOmitsEmptySections(t *testing.T) {
+	out, err := JSONFormatter{}.Format(sampleSnapshot(), DefaultFilter())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, key := range []string{`"metrics"`, `"service_checks"`, `"events"`} {
+		if !strings.Contains(out, key) {
+			t.Errorf("expected output to contain %s, got: %s", key, out)
+		}
+	}
+	if strings.Contains(out, `"sketches"`) {
+		t.Errorf("expected empty sketches to be omitted, got: %s", out)
+	}
+}
+
+func TestJSONFormatterHonorsFilter(t *testing.T) {
+	out, err := JSONFormatter{}.Format(sampleSnapshot(), Filter{Metrics: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out, `"metrics"`) {
+		t.Errorf("expected metrics to be included, got: %s", out)
+	}
+	if strings.Contains(out, `"service_checks"`) || strings.Contains(out, `"events"`) {
+		t.Errorf("expected filtered-out sections to be absent, got: %s", out)
+	}
+}
+
+func TestPrettyFormatterOnlyRendersNonEmptySections(t *testing.T) {
+	snapshot := sampleSnapshot()
+	snapshot.Events = nil
+
+	out, err := PrettyFormatter{}.Format(snapshot, DefaultFilter())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out, "Series") {
+		t.Errorf("expected a Series section, got: %s", out)
+	}
+	if strings.Contains(out, "Events") {
+		t.Errorf("expected no Events section for an empty slice, got: %s", out)
+	}
+}
+
+func TestInfluxFormatterRendersLineProtocol(t *testing.T) {
+	out, err := InfluxFormatter{}.Format(sampleSnapshot(), DefaultFilter())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantMetric := "system.cpu.idle,host=myhost,env=prod value=42.5 1500000000"
+	if !strings.Contains(out, wantMetric) {
+		t.Errorf("expected output to contain %q, got: %s", wantMetric, out)
+	}
+}
+
+func TestPrometheusFormatterSanitizesNamesAndQuotesLabels(t *testing.T) {
+	snapshot := sampleSnapshot()
+	snapshot.Series[0].Name = "system.cpu.idle"
+
+	out, err := PrometheusFormatter{}.Format(snapshot, DefaultFilter())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out, `system_cpu_idle{host="myhost",env="prod"}`) {
+		t.Errorf("expected sanitized metric name with quoted labels, got: %s", out)
+	}
+}